@@ -1,15 +1,15 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -18,10 +18,20 @@ import (
 const defaultConfigFile = "britto.toml"
 
 type Reminder struct {
-	Name          string `toml:"Name"`
-	Date          string `toml:"Date"`
-	Message       string `toml:"Message,omitempty"`
-	ReminderRange *int   `toml:"ReminderRange,omitempty"`
+	Name                string   `toml:"Name"`
+	Date                string   `toml:"Date"`
+	Message             string   `toml:"Message,omitempty"`
+	ReminderRange       *int     `toml:"ReminderRange,omitempty"`
+	NotifyVia           []string `toml:"NotifyVia,omitempty"`
+	NotifyDaysInAdvance []int    `toml:"NotifyDaysInAdvance,omitempty"`
+	PicturePath         string   `toml:"PicturePath,omitempty"`
+}
+
+// PicturesConfig is the `[Pictures]` section of britto.toml: a
+// directory searched for a portrait matching a Reminder's Name or
+// PicturePath.
+type PicturesConfig struct {
+	Dir string `toml:"Dir,omitempty"`
 }
 
 type ReminderRange struct {
@@ -45,6 +55,22 @@ type Config struct {
 	Reminders     []Reminder     `toml:"Reminder"`
 	ReminderRange ReminderRange  `toml:"ReminderRange"`
 	Template      TemplateConfig `toml:"template"`
+	Sources       Sources        `toml:"Sources"`
+	Notify        NotifyConfig   `toml:"Notify"`
+	Daemon        DaemonConfig   `toml:"Daemon"`
+	Pictures      PicturesConfig `toml:"Pictures"`
+
+	// MaxRelativeOffsetDays caps relative Date offsets like "90d" or "52w"
+	// so a typo can't schedule a reminder decades out. Defaults to
+	// defaultMaxRelativeOffsetDays when zero.
+	MaxRelativeOffsetDays int `toml:"MaxRelativeOffsetDays,omitempty"`
+}
+
+func (c Config) maxRelativeOffsetDays() int {
+	if c.MaxRelativeOffsetDays <= 0 {
+		return defaultMaxRelativeOffsetDays
+	}
+	return c.MaxRelativeOffsetDays
 }
 
 var defaultTemplate = TemplateConfig{
@@ -137,41 +163,127 @@ func saveDefaultConfig(configDir, configPath string) error {
 	return nil
 }
 
-func parseDate(dateStr string, now time.Time) (time.Time, int, error) {
+// defaultMaxRelativeOffsetDays caps relative offsets like "90d" or "52w"
+// so a typo can't schedule a reminder decades out. Config.MaxRelativeOffsetDays
+// overrides it.
+const defaultMaxRelativeOffsetDays = 90
+
+// dateLayout is one candidate absolute layout parseDate tries in order.
+// hasYear reports whether the layout itself encodes a year (as opposed
+// to one being assumed from now); fixed reports whether a successful
+// parse should be treated as an exact point in time rather than a date
+// that recurs yearly.
+type dateLayout struct {
+	layout  string
+	hasYear bool
+	fixed   bool
+}
+
+var dateLayouts = []dateLayout{
+	{"02/01", false, false},
+	{"02/01/2006", true, false},
+	{"2006-01-02", true, false},
+	{"02/01/2006-15:04:05", true, true},
+	{"02/01/2006-15:04", true, true},
+}
+
+// relativeOffsetPattern matches a leading numeric run followed by a
+// single unit character: seconds, minutes, hours, days or weeks.
+var relativeOffsetPattern = regexp.MustCompile(`^(\d+)([smhdw])$`)
+
+// clockPattern matches a bare HH:MM or HH:MM:SS, meaning "today at that
+// time, or tomorrow if that time has already passed".
+var clockPattern = regexp.MustCompile(`^\d{1,2}:\d{2}(:\d{2})?$`)
+
+// parseDate parses a Reminder.Date value. It returns the resolved
+// date/time, the year to diff ages against (0 when none was given),
+// whether dateStr actually encoded that year (as opposed to year being
+// today's year, assumed for display purposes only), and whether the
+// result is a fixed point in time (as opposed to a month/day that
+// recurs every year).
+func parseDate(dateStr string, now time.Time, maxOffsetDays int) (time.Time, int, bool, bool, error) {
 	if dateStr == "" {
-		return time.Time{}, 0, fmt.Errorf("date not provided")
+		return time.Time{}, 0, false, false, fmt.Errorf("date not provided")
+	}
+	if maxOffsetDays <= 0 {
+		maxOffsetDays = defaultMaxRelativeOffsetDays
 	}
 
-	var date time.Time
-	var err error
-	var year int
+	for _, candidate := range dateLayouts {
+		date, err := time.Parse(candidate.layout, dateStr)
+		if err != nil {
+			continue
+		}
 
-	if len(dateStr) == 5 {
-		// Parse date without year
-		date, err = time.Parse("02/01", dateStr)
-		if err == nil {
+		year := 0
+		if candidate.hasYear {
+			year = date.Year()
+		} else {
 			year = now.Year()
 			date = time.Date(year, date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
 		}
-	} else if len(dateStr) == 10 {
-		// Parse date with year
-		date, err = time.Parse("02/01/2006", dateStr)
-		if err == nil {
-			year, err = strconv.Atoi(dateStr[6:])
-			if err != nil {
-				return time.Time{}, 0, fmt.Errorf("failed to parse year: %v", err)
-			}
+		return date, year, candidate.hasYear, candidate.fixed, nil
+	}
+
+	if clockPattern.MatchString(dateStr) {
+		layout := "15:04"
+		if strings.Count(dateStr, ":") == 2 {
+			layout = "15:04:05"
+		}
+		clock, err := time.Parse(layout, dateStr)
+		if err != nil {
+			return time.Time{}, 0, false, false, fmt.Errorf("failed to parse clock time: %v", err)
+		}
+
+		date := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.UTC)
+		if date.Before(now) {
+			date = date.AddDate(0, 0, 1)
 		}
-	} else {
-		return time.Time{}, 0, fmt.Errorf("invalid date format")
+		return date, 0, false, true, nil
 	}
 
-	return date, year, nil
+	if matches := relativeOffsetPattern.FindStringSubmatch(dateStr); matches != nil {
+		amount, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return time.Time{}, 0, false, false, fmt.Errorf("invalid relative offset: %v", err)
+		}
+
+		var unit time.Duration
+		switch matches[2] {
+		case "s":
+			unit = time.Second
+		case "m":
+			unit = time.Minute
+		case "h":
+			unit = time.Hour
+		case "d":
+			unit = 24 * time.Hour
+		case "w":
+			unit = 7 * 24 * time.Hour
+		}
+
+		offset := time.Duration(amount) * unit
+		if max := time.Duration(maxOffsetDays) * 24 * time.Hour; offset > max {
+			offset = max
+		}
+		return now.Add(offset), 0, false, true, nil
+	}
+
+	return time.Time{}, 0, false, false, fmt.Errorf("invalid date format")
 }
 
-func processReminders(reminders []Reminder, now time.Time, isBirthday bool, defaultRange int, templateCfg TemplateConfig) {
+// processReminders evaluates reminders as of now (truncated to midnight,
+// so day-bucket math like daysUntilDate and year rollover stays stable
+// across a single scan regardless of what time of day it runs) but
+// resolves each reminder's Date against wallClock, the real,
+// untruncated current time. That split matters for sub-day Date forms
+// ("2h", "15:04", a combined date-time): parseDate needs the actual
+// current time to know whether a clock time has already passed today,
+// or to add a relative offset from "right now" rather than from
+// midnight.
+func processReminders(ctx context.Context, reminders []Reminder, now time.Time, wallClock time.Time, isBirthday bool, defaultRange int, templateCfg TemplateConfig, notifyCfg NotifyConfig, state notifyState, maxOffsetDays int, picturesDir string) {
 	for _, reminder := range reminders {
-		date, year, err := parseDate(reminder.Date, now)
+		date, year, _, fixed, err := parseDate(reminder.Date, wallClock, maxOffsetDays)
 		if err != nil {
 			log.Printf("[%s]: Failed to parse date: %v", reminder.Name, err)
 			continue
@@ -188,7 +300,22 @@ func processReminders(reminders []Reminder, now time.Time, isBirthday bool, defa
 			rangeDays = *reminder.ReminderRange
 		}
 
-		printReminder := func(daysUntilDate int, nextDate time.Time, year int) {
+		// Offsets at which to notify, in days-until-due. When none are
+		// configured we fall back to the historical behaviour of firing
+		// on every day inside rangeDays.
+		offsets := reminder.NotifyDaysInAdvance
+		if len(offsets) == 0 {
+			offsets = notifyCfg.NotifyDaysInAdvance
+		}
+
+		notifiers := notifiersFor(reminder, notifyCfg)
+
+		notify := func(daysUntilDate int, nextDate time.Time, year int) {
+			key := notifyStateKey(reminder.Name, reminder.Date, daysUntilDate, now.Format("2006-01-02"))
+			if state[key] {
+				return
+			}
+
 			var due string
 			if daysUntilDate == 0 {
 				due = templateCfg.DueToday
@@ -198,72 +325,112 @@ func processReminders(reminders []Reminder, now time.Time, isBirthday bool, defa
 				due = strings.ReplaceAll(templateCfg.DueIn, "{{.AgeOrDays}}", strconv.Itoa(daysUntilDate))
 			}
 
-			msg := reminder.Message
-
+			var formattedDate string
+			var tmpl string
 			if isBirthday {
 				age := nextDate.Year() - year
-				tmpl := templateCfg.Birthday
+				tmpl = templateCfg.Birthday
 				if age == 0 {
 					tmpl = templateCfg.Birthday0
 				}
-				formattedMsg := formatTemplate(tmpl, reminder.Name, strconv.Itoa(age), due, nextDate.Format(templateCfg.DateFormat))
-				fmt.Println(formattedMsg)
+				formattedDate = nextDate.Format(templateCfg.DateFormat)
 			} else {
-				formattedMsg := formatTemplate(templateCfg.Reminder, reminder.Name, strconv.Itoa(daysUntilDate), due, nextDate.Format(templateCfg.DateFormatShort))
-				fmt.Println(formattedMsg)
+				tmpl = templateCfg.Reminder
+				formattedDate = nextDate.Format(templateCfg.DateFormatShort)
 			}
 
-			// Print the message if it exists
-			if msg != "" {
-				fmt.Println(msg)
+			data := buildTemplateData(reminder, nextDate, year, daysUntilDate, isBirthday, due, formattedDate, picturesDir)
+			formattedMsg := formatTemplate(tmpl, data)
+
+			if reminder.Message != "" {
+				formattedMsg = formattedMsg + "\n" + reminder.Message
 			}
+
+			dispatch(ctx, notifiers, Event{
+				Name:       reminder.Name,
+				Message:    formattedMsg,
+				Due:        due,
+				Date:       reminder.Date,
+				IsBirthday: isBirthday,
+				DaysUntil:  daysUntilDate,
+				Picture:    data.Picture,
+			})
+
+			state[key] = true
 		}
 
-		for _, yearsAhead := range []int{0, 1} {
-			nextDate := time.Date(now.Year()+yearsAhead, date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+		evaluate := func(nextDate time.Time) {
 			if nextDate.Before(now) {
-				continue
+				return
 			}
 
 			daysUntilDate := int(nextDate.Sub(now).Hours() / 24)
-			if daysUntilDate <= rangeDays && daysUntilDate >= 0 {
-				printReminder(daysUntilDate, nextDate, year)
-				break
+			if daysUntilDate < 0 {
+				return
+			}
+
+			if len(offsets) > 0 {
+				for _, offset := range offsets {
+					if daysUntilDate == offset {
+						notify(daysUntilDate, nextDate, year)
+					}
+				}
+			} else if daysUntilDate <= rangeDays {
+				notify(daysUntilDate, nextDate, year)
+			}
+		}
+
+		if fixed {
+			// A full timestamp was given (relative offset, clock time, or
+			// combined date-time): it's a single point in time, not a
+			// month/day that recurs every year.
+			evaluate(date)
+		} else {
+			for _, yearsAhead := range []int{0, 1} {
+				nextDate := time.Date(now.Year()+yearsAhead, date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+				if !nextDate.Before(now) {
+					evaluate(nextDate)
+					break
+				}
 			}
 		}
 	}
 }
 
-func formatTemplate(tmplStr string, name string, ageOrDays string, due string, formattedDate string) string {
-	tmpl, err := template.New("tmpl").Parse(tmplStr)
-	if err != nil {
-		log.Fatalf("Failed to parse template: %v", err)
+// applyImportedSources merges vCard- and CardDAV-sourced birthdays into
+// config.Birthdays. It's called from every subcommand that loads a
+// Config via resolveConfig (the plain scan, --daemon, serve, and
+// export/import) so they all see the same reminder set regardless of
+// how the birthdays were originally declared.
+func applyImportedSources(config *Config, importVCardsPath string) {
+	if importVCardsPath != "" {
+		imported, err := importVCards(importVCardsPath)
+		if err != nil {
+			log.Fatalf("Failed to import vcards: %v", err)
+		}
+		config.Birthdays = append(config.Birthdays, imported...)
 	}
 
-	var buf bytes.Buffer
-	data := map[string]string{
-		"Name":      name,
-		"AgeOrDays": ageOrDays, // Depending on whether it's a birthday or event
-		"Due":       due,
-		"Date":      formattedDate,
-	}
-	err = tmpl.Execute(&buf, data)
-	if err != nil {
-		log.Fatalf("Failed to execute template: %v", err)
+	if config.Sources.CardDAV.BaseURL != "" {
+		imported, err := fetchAndDecodeCardDAV(config.Sources.CardDAV)
+		if err != nil {
+			log.Printf("Failed to import CardDAV birthdays: %v", err)
+		} else {
+			config.Birthdays = append(config.Birthdays, imported...)
+		}
 	}
-
-	return buf.String()
 }
 
-func main() {
-	configPathFlag := flag.String("config", "", "Path to the configuration directory")
-	flag.Parse()
-
-	configDir := *configPathFlag
+// resolveConfig locates the config directory (defaulting to
+// $XDG_CONFIG_HOME/britto, seeding it with defaultConfig on first run)
+// and loads it. It's shared by the normal scan and the export/import
+// subcommands so they all see the same reminders.
+func resolveConfig(configPathFlag string) (*Config, string, error) {
+	configDir := configPathFlag
 	if configDir == "" {
 		xdgConfigDir, err := os.UserConfigDir()
 		if err != nil {
-			log.Fatalf("Failed to get user config directory: %v", err)
+			return nil, "", fmt.Errorf("failed to get user config directory: %v", err)
 		}
 
 		configDir = filepath.Join(xdgConfigDir, "britto")
@@ -272,9 +439,8 @@ func main() {
 		configPath := filepath.Join(configDir, defaultConfigFile)
 		if _, err := os.Stat(configPath); os.IsNotExist(err) {
 			log.Printf("Config file does not exist. Creating a default config.")
-			err := saveDefaultConfig(configDir, configPath)
-			if err != nil {
-				log.Fatalf("Failed to save default config: %v", err)
+			if err := saveDefaultConfig(configDir, configPath); err != nil {
+				return nil, "", fmt.Errorf("failed to save default config: %v", err)
 			}
 			log.Printf("Default config saved to %s. Please edit it with your reminders.", configPath)
 		}
@@ -282,15 +448,139 @@ func main() {
 
 	config, err := loadConfig(configDir)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		return nil, "", fmt.Errorf("failed to load config: %v", err)
+	}
+	return config, configDir, nil
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "add" {
+		if len(os.Args) != 3 {
+			log.Fatalf("usage: britto add \"remind me in <N><s|m|h|d|w> <message>\"")
+		}
+		if err := sendAddCommand(os.Args[2]); err != nil {
+			log.Fatalf("Failed to add reminder: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		fs := flag.NewFlagSet("serve", flag.ExitOnError)
+		configPathFlag := fs.String("config", "", "Path to the configuration directory")
+		addrFlag := fs.String("addr", ":8080", "Address to listen on")
+		importVCardsFlag := fs.String("import-vcards", "", "Path to a .vcf file or directory of .vcf files to import birthdays from")
+		fs.Parse(os.Args[2:])
+
+		config, configDir, err := resolveConfig(*configPathFlag)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		applyImportedSources(config, *importVCardsFlag)
+
+		if err := runServe(configDir, config, *addrFlag); err != nil {
+			log.Fatalf("Serve exited: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && (os.Args[1] == "export" || os.Args[1] == "import") {
+		subcommand := os.Args[1]
+		fs := flag.NewFlagSet(subcommand, flag.ExitOnError)
+		configPathFlag := fs.String("config", "", "Path to the configuration directory")
+		icalFlag := fs.Bool("ical", false, "Export/import reminders as iCalendar")
+		icalFile := fs.String("file", "", "Path to the .ics file to import (required for \"britto import --ical\")")
+		importVCardsFlag := fs.String("import-vcards", "", "Path to a .vcf file or directory of .vcf files to import birthdays from")
+		fs.Parse(os.Args[2:])
+
+		if !*icalFlag {
+			log.Fatalf("usage: britto %s --ical [--file reminders.ics]", subcommand)
+		}
+
+		config, configDir, err := resolveConfig(*configPathFlag)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		if subcommand == "export" {
+			// "import" persists config back to britto.toml below, so
+			// vCard/CardDAV sources are deliberately left out here: they're
+			// re-fetched on every run, and writing them to disk would
+			// duplicate them on the next fetch.
+			applyImportedSources(config, *importVCardsFlag)
+
+			out, err := exportICal(config, time.Now())
+			if err != nil {
+				log.Fatalf("Failed to export ical: %v", err)
+			}
+			fmt.Print(out)
+			return
+		}
+
+		if *icalFile == "" {
+			log.Fatalf("usage: britto import --ical --file reminders.ics")
+		}
+		f, err := os.Open(*icalFile)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", *icalFile, err)
+		}
+		defer f.Close()
+
+		birthdays, reminders, err := importICal(f)
+		if err != nil {
+			log.Fatalf("Failed to import ical: %v", err)
+		}
+		config.Birthdays = append(config.Birthdays, birthdays...)
+		config.Reminders = append(config.Reminders, reminders...)
+
+		configPath := filepath.Join(configDir, defaultConfigFile)
+		file, err := os.Create(configPath)
+		if err != nil {
+			log.Fatalf("Failed to open %s for writing: %v", configPath, err)
+		}
+		defer file.Close()
+		if err := toml.NewEncoder(file).Encode(config); err != nil {
+			log.Fatalf("Failed to write %s: %v", configPath, err)
+		}
+		log.Printf("Imported %d birthdays and %d reminders into %s", len(birthdays), len(reminders), configPath)
+		return
+	}
+
+	configPathFlag := flag.String("config", "", "Path to the configuration directory")
+	importVCardsFlag := flag.String("import-vcards", "", "Path to a .vcf file or directory of .vcf files to import birthdays from")
+	daemonFlag := flag.Bool("daemon", false, "Stay resident and schedule reminders instead of scanning once and exiting")
+	flag.Parse()
+
+	config, _, err := resolveConfig(*configPathFlag)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
 
-	now := time.Now().Truncate(24 * time.Hour) // Truncate to remove the time component
+	applyImportedSources(config, *importVCardsFlag)
+
+	if *daemonFlag {
+		if err := runDaemon(config); err != nil {
+			log.Fatalf("Daemon exited: %v", err)
+		}
+		return
+	}
+
+	wallClock := time.Now()
+	now := wallClock.Truncate(24 * time.Hour) // Truncate to remove the time component
+
+	ctx := context.Background()
+	state, err := loadNotifyState()
+	if err != nil {
+		log.Fatalf("Failed to load notify state: %v", err)
+	}
 
 	// Process birthday reminders
-	processReminders(config.Birthdays, now, true, config.ReminderRange.Birthdays, config.Template)
+	processReminders(ctx, config.Birthdays, now, wallClock, true, config.ReminderRange.Birthdays, config.Template, config.Notify, state, config.maxRelativeOffsetDays(), config.Pictures.Dir)
 	// Process other reminders
-	processReminders(config.Reminders, now, false, config.ReminderRange.Events, config.Template)
+	processReminders(ctx, config.Reminders, now, wallClock, false, config.ReminderRange.Events, config.Template, config.Notify, state, config.maxRelativeOffsetDays(), config.Pictures.Dir)
+
+	if err := saveNotifyState(state); err != nil {
+		log.Fatalf("Failed to save notify state: %v", err)
+	}
 }
 
 func intPtr(i int) *int {
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/robfig/cron/v3"
+)
+
+// DaemonConfig is the `[Daemon]` section of britto.toml.
+type DaemonConfig struct {
+	CronSpec string `toml:"CronSpec,omitempty"` // default "0 8 * * *"
+}
+
+func (d DaemonConfig) cronSpec() string {
+	if d.CronSpec == "" {
+		return "0 8 * * *"
+	}
+	return d.CronSpec
+}
+
+// PendingReminder is a one-shot reminder scheduled at runtime through
+// the `britto add` socket command. Unlike the TOML-driven Birthdays and
+// Reminders it fires exactly once and is then dropped.
+type PendingReminder struct {
+	ID      string    `json:"id"`
+	When    time.Time `json:"when"`
+	Message string    `json:"message"`
+}
+
+func pendingStorePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pending.json"), nil
+}
+
+func loadPending() (map[string]PendingReminder, error) {
+	path, err := pendingStorePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]PendingReminder{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending reminders: %v", err)
+	}
+
+	pending := map[string]PendingReminder{}
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("failed to parse pending reminders: %v", err)
+	}
+	return pending, nil
+}
+
+func savePending(pending map[string]PendingReminder) error {
+	path, err := pendingStorePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending reminders: %v", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func socketPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "britto.sock"), nil
+}
+
+// scanAndNotify runs one notification pass over config: it loads the
+// on-disk notify state, dispatches any birthdays/reminders that just
+// entered their window, and persists the updated state back. This is
+// the shared core behind --daemon's cron schedule and `serve`'s
+// background notify loop (see runServe), so both dispatch notifications
+// the exact same way regardless of which *Config they currently hold.
+// mu, if non-nil, is held while reading config.Birthdays/Reminders; pass
+// nil when config isn't shared with a concurrent writer (as in serve,
+// where config itself is an immutable snapshot behind an atomic.Pointer).
+func scanAndNotify(ctx context.Context, config *Config, mu *sync.Mutex) {
+	if mu != nil {
+		mu.Lock()
+		defer mu.Unlock()
+	}
+
+	state, err := loadNotifyState()
+	if err != nil {
+		log.Printf("failed to load notify state: %v", err)
+		return
+	}
+
+	wallClock := time.Now()
+	now := wallClock.Truncate(24 * time.Hour)
+	processReminders(ctx, config.Birthdays, now, wallClock, true, config.ReminderRange.Birthdays, config.Template, config.Notify, state, config.maxRelativeOffsetDays(), config.Pictures.Dir)
+	processReminders(ctx, config.Reminders, now, wallClock, false, config.ReminderRange.Events, config.Template, config.Notify, state, config.maxRelativeOffsetDays(), config.Pictures.Dir)
+
+	if err := saveNotifyState(state); err != nil {
+		log.Printf("failed to save notify state: %v", err)
+	}
+}
+
+// startCardDAVRefresh, when config.Sources.CardDAV has both a BaseURL and
+// a FetchInterval set, spawns a goroutine that re-fetches the
+// addressbook every interval and replaces the previously-imported
+// CardDAV contacts in config.Birthdays with the fresh ones, leaving the
+// birthdays declared directly in britto.toml untouched. mu guards
+// config.Birthdays against the concurrent read in scanAndNotify's cron
+// tick. It's a no-op (nil error, no goroutine) when CardDAV isn't
+// configured or FetchInterval is unset.
+func startCardDAVRefresh(ctx context.Context, config *Config, mu *sync.Mutex) error {
+	cfg := config.Sources.CardDAV
+	if cfg.BaseURL == "" || cfg.FetchInterval == "" {
+		return nil
+	}
+
+	interval, err := time.ParseDuration(cfg.FetchInterval)
+	if err != nil {
+		return fmt.Errorf("invalid Sources.CardDAV.FetchInterval %q: %v", cfg.FetchInterval, err)
+	}
+
+	mu.Lock()
+	baseline := append([]Reminder{}, config.Birthdays...)
+	mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				imported, err := fetchAndDecodeCardDAV(cfg)
+				if err != nil {
+					log.Printf("[daemon] failed to refresh CardDAV birthdays: %v", err)
+					continue
+				}
+
+				mu.Lock()
+				config.Birthdays = append(append([]Reminder{}, baseline...), imported...)
+				mu.Unlock()
+				log.Printf("[daemon] refreshed %d CardDAV birthdays", len(imported))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// runDaemon keeps Britto resident: it recomputes the TOML-driven
+// birthdays/reminders on a cron schedule, reloads any still-future
+// one-shot reminders persisted from a previous run, and listens on a
+// Unix socket for `britto add` commands adding new ones at runtime.
+func runDaemon(config *Config) error {
+	ctx := context.Background()
+
+	pending, err := loadPending()
+	if err != nil {
+		return err
+	}
+
+	var birthdaysMu sync.Mutex
+	c := cron.New()
+	recompute := func() { scanAndNotify(ctx, config, &birthdaysMu) }
+
+	if _, err := c.AddFunc(config.Daemon.cronSpec(), recompute); err != nil {
+		return fmt.Errorf("failed to schedule daily recompute (%q): %v", config.Daemon.cronSpec(), err)
+	}
+
+	if err := startCardDAVRefresh(ctx, config, &birthdaysMu); err != nil {
+		log.Printf("[daemon] not refreshing CardDAV periodically: %v", err)
+	}
+
+	// pendingMu guards pending: it's written from handleAddConn (one
+	// goroutine per socket connection) and from the time.AfterFunc
+	// callbacks schedulePending registers, both of which can fire
+	// concurrently with each other.
+	var pendingMu sync.Mutex
+	for id, p := range pending {
+		schedulePending(ctx, c, config, pending, &pendingMu, id, p)
+	}
+
+	c.Start()
+	defer c.Stop()
+
+	return serveSocket(ctx, c, config, pending, &pendingMu)
+}
+
+// schedulePending arranges for a single pending reminder to fire once,
+// at p.When, dropping it from the store afterwards. Reminders whose
+// When has already passed are dropped immediately instead of firing.
+// mu guards all reads/writes of pending.
+func schedulePending(ctx context.Context, c *cron.Cron, config *Config, pending map[string]PendingReminder, mu *sync.Mutex, id string, p PendingReminder) {
+	delay := time.Until(p.When)
+	if delay <= 0 {
+		mu.Lock()
+		delete(pending, id)
+		mu.Unlock()
+		return
+	}
+
+	time.AfterFunc(delay, func() {
+		notifiers := notifiersFor(Reminder{}, config.Notify)
+		dispatch(ctx, notifiers, Event{
+			Name:    "Reminder",
+			Message: p.Message,
+		})
+
+		mu.Lock()
+		delete(pending, id)
+		err := savePending(pending)
+		mu.Unlock()
+		if err != nil {
+			log.Printf("[daemon] failed to persist pending reminders: %v", err)
+		}
+	})
+}
+
+// serveSocket listens for `britto add` clients and blocks until the
+// listener is closed or fails. mu guards all reads/writes of pending.
+func serveSocket(ctx context.Context, c *cron.Cron, config *Config, pending map[string]PendingReminder, mu *sync.Mutex) error {
+	path, err := socketPath()
+	if err != nil {
+		return err
+	}
+	os.Remove(path) // clear a stale socket from an unclean shutdown
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", path, err)
+	}
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return fmt.Errorf("socket accept failed: %v", err)
+		}
+
+		go handleAddConn(ctx, c, config, pending, mu, conn)
+	}
+}
+
+func handleAddConn(ctx context.Context, c *cron.Cron, config *Config, pending map[string]PendingReminder, mu *sync.Mutex, conn net.Conn) {
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+
+	when, message, err := parseAddCommand(line, time.Now())
+	if err != nil {
+		fmt.Fprintf(conn, "error: %v\n", err)
+		return
+	}
+
+	id := ulid.Make().String()
+	p := PendingReminder{ID: id, When: when, Message: message}
+
+	mu.Lock()
+	pending[id] = p
+	err = savePending(pending)
+	mu.Unlock()
+
+	if err != nil {
+		fmt.Fprintf(conn, "error: %v\n", err)
+		return
+	}
+
+	schedulePending(ctx, c, config, pending, mu, id, p)
+	fmt.Fprintf(conn, "ok: scheduled %s for %s\n", id, when.Format(time.RFC3339))
+}
+
+// addCommandPattern matches the "remind me in <offset> <message>" form
+// of `britto add`.
+var addCommandPattern = regexp.MustCompile(`^\s*(?:remind me )?in\s+(\d+)([smhdw])\s+(.+?)\s*$`)
+
+// parseAddCommand extracts a delay and message from a raw `britto add`
+// line, e.g. `remind me in 2h buy milk`.
+func parseAddCommand(line string, now time.Time) (time.Time, string, error) {
+	matches := addCommandPattern.FindStringSubmatch(line)
+	if matches == nil {
+		return time.Time{}, "", fmt.Errorf("could not parse %q, expected \"in <N><unit> <message>\"", line)
+	}
+
+	amount, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid amount: %v", err)
+	}
+
+	var unit time.Duration
+	switch matches[2] {
+	case "s":
+		unit = time.Second
+	case "m":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	case "w":
+		unit = 7 * 24 * time.Hour
+	}
+
+	return now.Add(time.Duration(amount) * unit), matches[3], nil
+}
+
+// sendAddCommand is the client side of `britto add "..."`: it connects
+// to the running daemon's socket and forwards the raw command line.
+func sendAddCommand(text string) error {
+	path, err := socketPath()
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon at %s: %v", path, err)
+	}
+	defer conn.Close()
+
+	if _, err := fmt.Fprintf(conn, "%s\n", text); err != nil {
+		return fmt.Errorf("failed to send command: %v", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read daemon reply: %v", err)
+	}
+	fmt.Print(reply)
+	return nil
+}
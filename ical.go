@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// exportICal renders every Birthday as a yearly-recurring VEVENT and
+// every Reminder as a VTODO with a VALARM whose TRIGGER mirrors the
+// reminder's effective ReminderRange, so the result round-trips with
+// CalDAV clients like Nextcloud, Vikunja or Thunderbird.
+func exportICal(config *Config, now time.Time) (string, error) {
+	cal := ics.NewCalendar()
+	cal.SetMethod(ics.MethodPublish)
+
+	for i, b := range config.Birthdays {
+		date, year, hasYear, _, err := parseDate(b.Date, now, config.maxRelativeOffsetDays())
+		if err != nil {
+			continue
+		}
+
+		event := cal.AddEvent(fmt.Sprintf("britto-birthday-%d@britto", i))
+		event.SetCreatedTime(now)
+		event.SetDtStampTime(now)
+		event.SetStartAt(date)
+		event.SetAllDayStartAt(date)
+		event.SetSummary(b.Name)
+		if hasYear {
+			event.SetDescription(fmt.Sprintf("Born %d", year))
+		}
+		event.AddRrule("FREQ=YEARLY")
+	}
+
+	for i, r := range config.Reminders {
+		date, _, _, _, err := parseDate(r.Date, now, config.maxRelativeOffsetDays())
+		if err != nil {
+			continue
+		}
+
+		rangeDays := config.ReminderRange.Events
+		if r.ReminderRange != nil {
+			rangeDays = *r.ReminderRange
+		}
+
+		todo := cal.AddTodo(fmt.Sprintf("britto-reminder-%d@britto", i))
+		todo.SetCreatedTime(now)
+		todo.SetDtStampTime(now)
+		todo.SetDueAt(date)
+		todo.SetSummary(r.Name)
+		if r.Message != "" {
+			todo.SetDescription(r.Message)
+		}
+
+		alarm := todo.AddAlarm()
+		alarm.SetAction(ics.ActionDisplay)
+		alarm.SetTrigger(fmt.Sprintf("-P%dD", rangeDays))
+		alarm.SetDescription(r.Name)
+	}
+
+	return cal.Serialize(), nil
+}
+
+// triggerDaysPattern extracts the numeric day/time component out of a
+// VALARM TRIGGER duration such as "-P10D" or "-PT30M".
+var triggerDaysPattern = regexp.MustCompile(`^-P(?:(\d+)D)?(?:T(\d+)M)?$`)
+
+// triggerToReminderRange converts a VALARM TRIGGER like "-P10D" or
+// "-PT30M" into a ReminderRange override expressed in whole days,
+// rounding sub-day offsets up to one day so they still fire.
+func triggerToReminderRange(trigger string) (int, error) {
+	matches := triggerDaysPattern.FindStringSubmatch(strings.TrimSpace(trigger))
+	if matches == nil {
+		return 0, fmt.Errorf("unrecognized TRIGGER format %q", trigger)
+	}
+
+	if matches[1] != "" {
+		days, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid TRIGGER day count: %v", err)
+		}
+		return days, nil
+	}
+
+	// A minutes-only trigger (e.g. "-PT30M") still means "remind me
+	// ahead of time", so it maps to the smallest whole-day range.
+	return 1, nil
+}
+
+// importICal reads VEVENT/VTODO entries from r and converts them back
+// into Birthday/Reminder entries: VEVENTs become Birthdays, VTODOs
+// become Reminders with their ReminderRange taken from the first
+// VALARM's TRIGGER, if any.
+func importICal(r io.Reader) (birthdays []Reminder, reminders []Reminder, err error) {
+	cal, err := ics.ParseCalendar(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse ical file: %v", err)
+	}
+
+	for _, event := range cal.Events() {
+		start, err := event.GetStartAt()
+		if err != nil {
+			continue
+		}
+
+		birthdays = append(birthdays, Reminder{
+			Name: propertyValue(event.GetProperty(ics.ComponentPropertySummary)),
+			Date: start.Format("02/01/2006"),
+		})
+	}
+
+	for _, todo := range cal.Todos() {
+		due, err := todo.GetDueAt()
+		if err != nil {
+			continue
+		}
+
+		reminder := Reminder{
+			Name: propertyValue(todo.GetProperty(ics.ComponentPropertySummary)),
+			Date: due.Format("02/01/2006"),
+		}
+
+		if alarms := todo.Alarms(); len(alarms) > 0 {
+			trigger := propertyValue(alarms[0].GetProperty(ics.ComponentPropertyTrigger))
+			if rangeDays, err := triggerToReminderRange(trigger); err == nil {
+				reminder.ReminderRange = intPtr(rangeDays)
+			}
+		}
+
+		reminders = append(reminders, reminder)
+	}
+
+	return birthdays, reminders, nil
+}
+
+func propertyValue(prop *ics.IANAProperty) string {
+	if prop == nil {
+		return ""
+	}
+	return prop.Value
+}
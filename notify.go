@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Event is what gets handed to a Notifier once a Reminder has been
+// determined to be due. It carries the already-rendered message plus
+// enough raw fields for backends that want to build their own payload.
+type Event struct {
+	Name       string
+	Message    string
+	Due        string
+	Date       string
+	IsBirthday bool
+	DaysUntil  int
+	Picture    string // path to a portrait, attached by backends that support it
+}
+
+// Notifier delivers a due Event somewhere. Implementations must be safe
+// to call from the daemon's scheduler goroutine.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// logNotifier is the original behaviour: print to stdout. It's always
+// registered under the name "log" and used whenever Notify.Via is empty.
+type logNotifier struct{}
+
+func (logNotifier) Notify(_ context.Context, event Event) error {
+	fmt.Println(event.Message)
+	return nil
+}
+
+// PushoverConfig holds the application/user tokens needed to send a
+// push notification via https://api.pushover.net.
+type PushoverConfig struct {
+	Token string `toml:"Token"`
+	User  string `toml:"User"`
+}
+
+type pushoverNotifier struct {
+	cfg PushoverConfig
+}
+
+func (p pushoverNotifier) Notify(ctx context.Context, event Event) error {
+	form := url.Values{
+		"token":   {p.cfg.Token},
+		"user":    {p.cfg.User},
+		"message": {event.Message},
+		"title":   {event.Name},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.pushover.net/1/messages.json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("pushover: failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushover: request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pushover: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// SlackConfig is a single incoming webhook URL.
+type SlackConfig struct {
+	WebhookURL string `toml:"WebhookURL"`
+}
+
+type slackNotifier struct {
+	cfg SlackConfig
+}
+
+func (s slackNotifier) Notify(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(map[string]string{"text": event.Message})
+	if err != nil {
+		return fmt.Errorf("slack: failed to marshal payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("slack: failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack: request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// EmailConfig describes an SMTP relay used to deliver plain-text mail.
+type EmailConfig struct {
+	Host     string `toml:"Host"`
+	Port     int    `toml:"Port"`
+	User     string `toml:"User"`
+	Password string `toml:"Password"`
+	From     string `toml:"From"`
+	To       string `toml:"To"`
+}
+
+type emailNotifier struct {
+	cfg EmailConfig
+}
+
+func (e emailNotifier) Notify(_ context.Context, event Event) error {
+	addr := fmt.Sprintf("%s:%d", e.cfg.Host, e.cfg.Port)
+	auth := smtp.PlainAuth("", e.cfg.User, e.cfg.Password, e.cfg.Host)
+
+	body, err := e.buildMessage(event)
+	if err != nil {
+		return fmt.Errorf("email: failed to build message: %v", err)
+	}
+
+	if err := smtp.SendMail(addr, auth, e.cfg.From, []string{e.cfg.To}, body); err != nil {
+		return fmt.Errorf("email: failed to send: %v", err)
+	}
+	return nil
+}
+
+// buildMessage renders a plain-text email, or a multipart/mixed one
+// with event.Picture attached when set.
+func (e emailNotifier) buildMessage(event Event) ([]byte, error) {
+	if event.Picture == "" {
+		return []byte(fmt.Sprintf("Subject: %s\r\n\r\n%s\r\n", event.Name, event.Message)), nil
+	}
+
+	data, err := os.ReadFile(event.Picture)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read picture: %v", err)
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "Subject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n", event.Name, writer.Boundary())
+
+	textPart, err := writer.CreatePart(map[string][]string{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	textPart.Write([]byte(event.Message))
+
+	attachmentHeader := map[string][]string{
+		"Content-Type":              {mime.TypeByExtension(filepath.Ext(event.Picture))},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {fmt.Sprintf(`attachment; filename="%s"`, filepath.Base(event.Picture))},
+	}
+	attachmentPart, err := writer.CreatePart(attachmentHeader)
+	if err != nil {
+		return nil, err
+	}
+	encoder := base64.NewEncoder(base64.StdEncoding, attachmentPart)
+	encoder.Write(data)
+	encoder.Close()
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// TelegramConfig points at a bot and the chat it should post to.
+type TelegramConfig struct {
+	BotToken string `toml:"BotToken"`
+	ChatID   string `toml:"ChatID"`
+}
+
+type telegramNotifier struct {
+	cfg TelegramConfig
+}
+
+func (t telegramNotifier) Notify(ctx context.Context, event Event) error {
+	if event.Picture != "" {
+		return t.sendPhoto(ctx, event)
+	}
+
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.cfg.BotToken)
+	form := url.Values{
+		"chat_id": {t.cfg.ChatID},
+		"text":    {event.Message},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("telegram: failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram: request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// sendPhoto posts event.Picture as the bot's sendPhoto payload, with
+// event.Message as the caption.
+func (t telegramNotifier) sendPhoto(ctx context.Context, event Event) error {
+	f, err := os.Open(event.Picture)
+	if err != nil {
+		return fmt.Errorf("telegram: failed to open picture: %v", err)
+	}
+	defer f.Close()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("chat_id", t.cfg.ChatID)
+	writer.WriteField("caption", event.Message)
+
+	part, err := writer.CreateFormFile("photo", filepath.Base(event.Picture))
+	if err != nil {
+		return fmt.Errorf("telegram: failed to build multipart form: %v", err)
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return fmt.Errorf("telegram: failed to attach picture: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("telegram: failed to finalize multipart form: %v", err)
+	}
+
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendPhoto", t.cfg.BotToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, &body)
+	if err != nil {
+		return fmt.Errorf("telegram: failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram: request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// NotifyConfig is the `[Notify]` section of britto.toml: which backends
+// are active by default (Via) and their respective credentials.
+type NotifyConfig struct {
+	Via                 []string       `toml:"Via"`
+	NotifyDaysInAdvance []int          `toml:"NotifyDaysInAdvance,omitempty"`
+	Pushover            PushoverConfig `toml:"Pushover"`
+	Slack               SlackConfig    `toml:"Slack"`
+	Email               EmailConfig    `toml:"Email"`
+	Telegram            TelegramConfig `toml:"Telegram"`
+}
+
+// buildNotifier resolves a backend name to its Notifier implementation.
+// Unknown names fall back to the log notifier with a warning, so a typo
+// in the config never silently swallows reminders.
+func buildNotifier(name string, cfg NotifyConfig) Notifier {
+	switch name {
+	case "", "log":
+		return logNotifier{}
+	case "pushover":
+		return pushoverNotifier{cfg: cfg.Pushover}
+	case "slack":
+		return slackNotifier{cfg: cfg.Slack}
+	case "email":
+		return emailNotifier{cfg: cfg.Email}
+	case "telegram":
+		return telegramNotifier{cfg: cfg.Telegram}
+	default:
+		log.Printf("[notify] unknown backend %q, falling back to log", name)
+		return logNotifier{}
+	}
+}
+
+// notifiersFor resolves the Via list that applies to a single reminder:
+// its own NotifyVia override if set, otherwise the global Notify.Via,
+// falling back to "log" if neither is configured.
+func notifiersFor(reminder Reminder, cfg NotifyConfig) []Notifier {
+	via := reminder.NotifyVia
+	if len(via) == 0 {
+		via = cfg.Via
+	}
+	if len(via) == 0 {
+		via = []string{"log"}
+	}
+
+	notifiers := make([]Notifier, 0, len(via))
+	for _, name := range via {
+		notifiers = append(notifiers, buildNotifier(name, cfg))
+	}
+	return notifiers
+}
+
+// dispatch sends event through every notifier, logging (not aborting
+// the scan on) individual backend failures.
+func dispatch(ctx context.Context, notifiers []Notifier, event Event) {
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, event); err != nil {
+			log.Printf("[notify] %s: %v", event.Name, err)
+		}
+	}
+}
+
+// notifyState tracks which (reminder, day-offset) pairs have already
+// been sent today, so a daemon that recomputes several times a day
+// doesn't re-notify. Keyed by "<name>|<date>|<daysUntil>|<today>".
+type notifyState map[string]bool
+
+func notifyStateKey(name, date string, daysUntil int, today string) string {
+	return fmt.Sprintf("%s|%s|%d|%s", name, date, daysUntil, today)
+}
+
+func notifyStatePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "notified.json"), nil
+}
+
+func loadNotifyState() (notifyState, error) {
+	path, err := notifyStatePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return notifyState{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notify state: %v", err)
+	}
+
+	var state notifyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse notify state: %v", err)
+	}
+	return state, nil
+}
+
+func saveNotifyState(state notifyState) error {
+	path, err := notifyStatePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notify state: %v", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// stateDir resolves $XDG_STATE_HOME/britto, falling back to
+// ~/.local/state/britto when the environment variable isn't set.
+func stateDir() (string, error) {
+	if xdg := os.Getenv("XDG_STATE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "britto"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %v", err)
+	}
+	return filepath.Join(home, ".local", "state", "britto"), nil
+}
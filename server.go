@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// UpcomingEntry is a single row of the `/next` feed: one birthday or
+// reminder that falls within the requested window.
+type UpcomingEntry struct {
+	Name      string `json:"Name"`
+	Date      string `json:"Date"`
+	DaysUntil int    `json:"DaysUntil"`
+	Age       int    `json:"Age,omitempty"`
+	Message   string `json:"Message,omitempty"`
+	Rendered  string `json:"Rendered"`
+}
+
+// server shares the loaded *Config between the HTTP handlers, the
+// SIGHUP reload goroutine, and the background notify loop via an atomic
+// pointer, so a reload can swap the config without any of them ever
+// observing a half-written one.
+type server struct {
+	config     atomic.Pointer[Config]
+	configDir  string
+	configPath string
+}
+
+// runServe starts the HTTP API on addr and blocks until the process is
+// signaled to stop. SIGHUP triggers a config reload in place; existing
+// connections are unaffected since handlers always read the current
+// atomic snapshot. Alongside the API, it runs the same cron-scheduled
+// scanAndNotify pass as --daemon, reading the current atomic config
+// snapshot on every tick so a SIGHUP reload changes what gets notified
+// too, not just what /next reports.
+func runServe(configDir string, config *Config, addr string) error {
+	srv := &server{configDir: configDir, configPath: resolveConfigPath(configDir)}
+	srv.config.Store(config)
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			reloaded, err := loadConfig(srv.configDir)
+			if err != nil {
+				log.Printf("[serve] reload failed, keeping previous config: %v", err)
+				continue
+			}
+			srv.config.Store(reloaded)
+			log.Printf("[serve] config reloaded from %s", srv.configDir)
+		}
+	}()
+
+	ctx := context.Background()
+	notifyCron := cron.New()
+	cronSpec := config.Daemon.cronSpec()
+	if _, err := notifyCron.AddFunc(cronSpec, func() {
+		scanAndNotify(ctx, srv.config.Load(), nil)
+	}); err != nil {
+		return fmt.Errorf("failed to schedule notify loop (%q): %v", cronSpec, err)
+	}
+	notifyCron.Start()
+	defer notifyCron.Stop()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", srv.handleHealth)
+	mux.HandleFunc("/next", srv.handleNext)
+	mux.HandleFunc("/next.ics", srv.handleNextICal)
+
+	httpServer := &http.Server{Addr: addr, Handler: mux}
+	log.Printf("[serve] listening on %s", addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("http server failed: %v", err)
+	}
+	return nil
+}
+
+func resolveConfigPath(configDir string) string {
+	return configDir + string(os.PathSeparator) + defaultConfigFile
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	config := s.config.Load()
+	loaded := len(config.Birthdays) + len(config.Reminders)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"ok":     true,
+		"loaded": loaded,
+		"config": s.configPath,
+	})
+}
+
+func (s *server) handleNext(w http.ResponseWriter, r *http.Request) {
+	limit, days := nextQueryParams(r)
+	wallClock := time.Now()
+	entries := upcomingEntries(s.config.Load(), wallClock.Truncate(24*time.Hour), wallClock, days, limit)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (s *server) handleNextICal(w http.ResponseWriter, r *http.Request) {
+	config := s.config.Load()
+	out, err := exportICal(config, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar")
+	fmt.Fprint(w, out)
+}
+
+func nextQueryParams(r *http.Request) (limit, days int) {
+	limit = 10
+	days = 30
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	if v := r.URL.Query().Get("days"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			days = n
+		}
+	}
+	return limit, days
+}
+
+// upcomingEntries collects every Birthday/Reminder whose next
+// occurrence falls within days from now, sorted ascending by
+// DaysUntil, capped at limit. now (truncated to midnight) drives the
+// day-bucket math so DaysUntil stays stable regardless of time of day;
+// wallClock, the real current time, is passed to parseDate so sub-day
+// Date forms ("2h", "15:04") resolve against the actual current time
+// rather than midnight.
+func upcomingEntries(config *Config, now time.Time, wallClock time.Time, days, limit int) []UpcomingEntry {
+	var entries []UpcomingEntry
+
+	collect := func(reminders []Reminder, isBirthday bool, templateCfg TemplateConfig) {
+		for _, reminder := range reminders {
+			date, year, _, fixed, err := parseDate(reminder.Date, wallClock, config.maxRelativeOffsetDays())
+			if err != nil {
+				continue
+			}
+
+			nextDate := date
+			if !fixed {
+				for _, yearsAhead := range []int{0, 1} {
+					candidate := time.Date(now.Year()+yearsAhead, date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+					if !candidate.Before(now) {
+						nextDate = candidate
+						break
+					}
+				}
+			}
+			if nextDate.Before(now) {
+				continue
+			}
+
+			daysUntil := int(nextDate.Sub(now).Hours() / 24)
+			if daysUntil > days {
+				continue
+			}
+
+			entry := UpcomingEntry{
+				Name:      reminder.Name,
+				Date:      reminder.Date,
+				DaysUntil: daysUntil,
+				Message:   reminder.Message,
+			}
+			if isBirthday && year > 0 {
+				entry.Age = nextDate.Year() - year
+			}
+			entry.Rendered = renderUpcomingEntry(reminder, nextDate, year, entry, isBirthday, templateCfg, config.Pictures.Dir)
+
+			entries = append(entries, entry)
+		}
+	}
+
+	collect(config.Birthdays, true, config.Template)
+	collect(config.Reminders, false, config.Template)
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].DaysUntil < entries[j].DaysUntil })
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+func renderUpcomingEntry(reminder Reminder, nextDate time.Time, year int, entry UpcomingEntry, isBirthday bool, templateCfg TemplateConfig, picturesDir string) string {
+	var due string
+	switch entry.DaysUntil {
+	case 0:
+		due = templateCfg.DueToday
+	case 1:
+		due = templateCfg.DueTomorrow
+	default:
+		due = fmt.Sprintf("in %d days", entry.DaysUntil)
+	}
+
+	tmpl := templateCfg.Reminder
+	formattedDate := nextDate.Format(templateCfg.DateFormatShort)
+	if isBirthday {
+		tmpl = templateCfg.Birthday
+		if entry.Age == 0 {
+			tmpl = templateCfg.Birthday0
+		}
+		formattedDate = nextDate.Format(templateCfg.DateFormat)
+	}
+
+	data := buildTemplateData(reminder, nextDate, year, entry.DaysUntil, isBirthday, due, formattedDate, picturesDir)
+	return formatTemplate(tmpl, data)
+}
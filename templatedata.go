@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData is what britto.toml templates are executed against.
+// Name/AgeOrDays/Due/Date are kept as top-level fields so existing
+// templates keep working unchanged (Date here is the *formatted*
+// date, matching the historical behaviour); Reminder exposes every
+// other raw field (Message, PicturePath, ...), and the rest are
+// computed for this particular occurrence.
+type TemplateData struct {
+	Reminder Reminder
+
+	Name      string
+	AgeOrDays string // rendered age (birthdays) or days-until (reminders)
+	Due       string
+	Date      string // formatted per TemplateConfig.DateFormat/DateFormatShort
+
+	YearOfBirth    int
+	NextOccurrence time.Time
+	Weekday        string
+	Zodiac         string
+	DaysUntil      int
+	IsBirthday     bool
+	Picture        string
+}
+
+// staticTemplateFuncs holds the helpers that don't depend on the
+// occurrence being rendered. getYearsOld is built per-call instead (see
+// formatTemplate) because it must diff against the occurrence's year,
+// not the year the daemon happens to be running in.
+var staticTemplateFuncs = template.FuncMap{
+	"ordinal":   ordinal,
+	"humanDate": func(t time.Time) string { return t.Format("Monday, 02 Jan 2006") },
+	"lower":     strings.ToLower,
+	"title":     strings.Title,
+}
+
+// ordinal renders an integer as "1st", "2nd", "3rd", "4th", ... "11th",
+// "12th", "13th", handling the 11-13 exception.
+func ordinal(n int) string {
+	if n%100 >= 11 && n%100 <= 13 {
+		return strconv.Itoa(n) + "th"
+	}
+	switch n % 10 {
+	case 1:
+		return strconv.Itoa(n) + "st"
+	case 2:
+		return strconv.Itoa(n) + "nd"
+	case 3:
+		return strconv.Itoa(n) + "rd"
+	default:
+		return strconv.Itoa(n) + "th"
+	}
+}
+
+// zodiacSign returns the Western zodiac sign for a given month/day.
+func zodiacSign(month time.Month, day int) string {
+	switch {
+	case month == time.December && day >= 22 || month == time.January && day <= 19:
+		return "Capricorn"
+	case month == time.January && day >= 20 || month == time.February && day <= 18:
+		return "Aquarius"
+	case month == time.February && day >= 19 || month == time.March && day <= 20:
+		return "Pisces"
+	case month == time.March && day >= 21 || month == time.April && day <= 19:
+		return "Aries"
+	case month == time.April && day >= 20 || month == time.May && day <= 20:
+		return "Taurus"
+	case month == time.May && day >= 21 || month == time.June && day <= 20:
+		return "Gemini"
+	case month == time.June && day >= 21 || month == time.July && day <= 22:
+		return "Cancer"
+	case month == time.July && day >= 23 || month == time.August && day <= 22:
+		return "Leo"
+	case month == time.August && day >= 23 || month == time.September && day <= 22:
+		return "Virgo"
+	case month == time.September && day >= 23 || month == time.October && day <= 22:
+		return "Libra"
+	case month == time.October && day >= 23 || month == time.November && day <= 21:
+		return "Scorpio"
+	default:
+		return "Sagittarius"
+	}
+}
+
+// buildTemplateData assembles the full TemplateData for a single
+// occurrence of reminder. formattedDate is the already-formatted date
+// string (per TemplateConfig.DateFormat/DateFormatShort) so it keeps
+// matching what existing {{.Date}} templates expect.
+func buildTemplateData(reminder Reminder, nextDate time.Time, yearOfBirth int, daysUntil int, isBirthday bool, due string, formattedDate string, picturesDir string) TemplateData {
+	ageOrDays := strconv.Itoa(daysUntil)
+	if isBirthday && yearOfBirth > 0 {
+		ageOrDays = strconv.Itoa(nextDate.Year() - yearOfBirth)
+	}
+
+	return TemplateData{
+		Reminder:       reminder,
+		Name:           reminder.Name,
+		AgeOrDays:      ageOrDays,
+		Due:            due,
+		Date:           formattedDate,
+		YearOfBirth:    yearOfBirth,
+		NextOccurrence: nextDate,
+		Weekday:        nextDate.Weekday().String(),
+		Zodiac:         zodiacSign(nextDate.Month(), nextDate.Day()),
+		DaysUntil:      daysUntil,
+		IsBirthday:     isBirthday,
+		Picture:        resolvePicture(reminder, picturesDir),
+	}
+}
+
+// resolvePicture looks up a portrait for reminder under picturesDir,
+// first by PicturePath, then by Name plus a handful of common image
+// extensions. It returns "" (not an error) when nothing matches, so
+// templates and notifiers degrade gracefully when no picture exists.
+func resolvePicture(reminder Reminder, picturesDir string) string {
+	if reminder.PicturePath != "" {
+		path := reminder.PicturePath
+		if !filepath.IsAbs(path) && picturesDir != "" {
+			path = filepath.Join(picturesDir, path)
+		}
+		if fileExists(path) {
+			return path
+		}
+		return ""
+	}
+
+	if picturesDir == "" {
+		return ""
+	}
+
+	for _, ext := range []string{".jpg", ".jpeg", ".png", ".webp"} {
+		path := filepath.Join(picturesDir, reminder.Name+ext)
+		if fileExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// formatTemplate executes tmplStr against data, with helpers like
+// {{ordinal .DaysUntil}} or {{getYearsOld .YearOfBirth}} registered.
+// getYearsOld diffs against data.NextOccurrence's year rather than the
+// current year, so a birthday whose next occurrence rolls into next
+// year (e.g. evaluated in late December) reports the age it's about to
+// turn, matching AgeOrDays.
+func formatTemplate(tmplStr string, data TemplateData) string {
+	asOfYear := time.Now().Year()
+	if !data.NextOccurrence.IsZero() {
+		asOfYear = data.NextOccurrence.Year()
+	}
+
+	funcMap := template.FuncMap{
+		"getYearsOld": func(yearOfBirth int) int {
+			if yearOfBirth <= 0 {
+				return 0
+			}
+			return asOfYear - yearOfBirth
+		},
+	}
+	for name, fn := range staticTemplateFuncs {
+		funcMap[name] = fn
+	}
+
+	tmpl, err := template.New("tmpl").Funcs(funcMap).Parse(tmplStr)
+	if err != nil {
+		log.Fatalf("Failed to parse template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Fatalf("Failed to execute template: %v", err)
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatTemplateRichFields(t *testing.T) {
+	reminder := Reminder{Name: "Ada Lovelace", Date: "10/12/1815", Message: "Send flowers"}
+	nextDate := time.Date(2026, time.December, 10, 0, 0, 0, 0, time.UTC)
+	data := buildTemplateData(reminder, nextDate, 1815, 5, true, "in 5 days", "10/12/2026", "")
+
+	got := formatTemplate("{{.Name}} turns {{getYearsOld .YearOfBirth}} ({{ordinal .DaysUntil}} day), a {{.Zodiac}} born on a {{.Weekday}}", data)
+	want := "Ada Lovelace turns 211 (5th day), a Sagittarius born on a Thursday"
+	if got != want {
+		t.Errorf("formatTemplate() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatTemplateDegradesGracefullyOnEmptyFields(t *testing.T) {
+	reminder := Reminder{Name: "Unknown Event", Date: "31/12"}
+	nextDate := time.Date(2026, time.December, 31, 0, 0, 0, 0, time.UTC)
+	data := buildTemplateData(reminder, nextDate, 0, 2, false, "in 2 days", "31/12", "")
+
+	got := formatTemplate("[{{.Name}}] due {{.Due}}. Picture: \"{{.Picture}}\". Age: {{getYearsOld .YearOfBirth}}", data)
+	want := "[Unknown Event] due in 2 days. Picture: \"\". Age: 0"
+	if got != want {
+		t.Errorf("formatTemplate() = %q, want %q", got, want)
+	}
+
+	if data.Picture != "" {
+		t.Errorf("expected no picture to resolve without a Pictures.Dir, got %q", data.Picture)
+	}
+}
+
+func TestFormatTemplateGetYearsOldMatchesAgeOrDaysAcrossYearRoll(t *testing.T) {
+	// A May birthday evaluated in late December: its next occurrence
+	// rolls into next year, so both AgeOrDays and getYearsOld must agree
+	// on the age the person is about to turn, not the current year.
+	reminder := Reminder{Name: "May Birthday", Date: "01/05/2000"}
+	nextDate := time.Date(2027, time.May, 1, 0, 0, 0, 0, time.UTC)
+	data := buildTemplateData(reminder, nextDate, 2000, 124, true, "in 124 days", "01/05/2027", "")
+
+	if data.AgeOrDays != "27" {
+		t.Fatalf("AgeOrDays = %q, want %q", data.AgeOrDays, "27")
+	}
+
+	got := formatTemplate("{{getYearsOld .YearOfBirth}}", data)
+	if got != data.AgeOrDays {
+		t.Errorf("getYearsOld = %q, want it to match AgeOrDays %q", got, data.AgeOrDays)
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	cases := map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 22: "22nd", 23: "23rd"}
+	for n, want := range cases {
+		if got := ordinal(n); got != want {
+			t.Errorf("ordinal(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestResolvePictureByName(t *testing.T) {
+	dir := t.TempDir()
+	picturePath := dir + "/Grace Hopper.jpg"
+	if err := os.WriteFile(picturePath, []byte{}, 0644); err != nil {
+		t.Fatalf("failed to set up fixture: %v", err)
+	}
+
+	reminder := Reminder{Name: "Grace Hopper"}
+	if got := resolvePicture(reminder, dir); got != picturePath {
+		t.Errorf("resolvePicture() = %q, want %q", got, picturePath)
+	}
+
+	if got := resolvePicture(Reminder{Name: "Nobody"}, dir); got != "" {
+		t.Errorf("resolvePicture() for unmatched name = %q, want \"\"", got)
+	}
+}
+
+func TestFormatTemplateLowerTitlePassthrough(t *testing.T) {
+	data := buildTemplateData(Reminder{Name: "Alan Turing", Date: "23/06/1912"}, time.Now(), 1912, 0, true, "today", "23/06", "")
+
+	got := formatTemplate("{{lower .Name}} / {{title (lower .Name)}}", data)
+	if !strings.Contains(got, "alan turing") || !strings.Contains(got, "Alan Turing") {
+		t.Errorf("formatTemplate() = %q, want lower/title passthroughs to round-trip", got)
+	}
+}